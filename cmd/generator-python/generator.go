@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"io"
@@ -9,10 +10,12 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	texttemplate "text/template"
 
 	"github.com/BurntSushi/toml"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
 
 	"github.com/bacongobbler/kubed-generator-sdk-go/manifest"
 	"github.com/bacongobbler/kubed-generator-sdk-go/pack"
@@ -22,36 +25,45 @@ const (
 	environmentEnvVar = "KUBED_ENV"
 	globalUsage       = `Generates boilerplate code that is necessary to write a python app.
 `
-	dockerfile = `FROM python
+	defaultFramework     = "flask"
+	defaultPythonVersion = "3.11"
+	defaultBaseImage     = "python"
+
+	dockerIgnore = `__pycache__/
+*.pyc
+.venv/
+.git/
+*.egg-info/
+`
+	dockerfileTemplate = `FROM {{ .BaseImage }}:{{ .PythonVersion }} AS builder
 
 WORKDIR /usr/src/app
 COPY requirements.txt ./
-RUN pip install --no-cache-dir -r requirements.txt
-
-COPY . .
-
-ENV PORT 8080
-EXPOSE 8080
+RUN pip install --no-cache-dir --prefix=/install -r requirements.txt
 
-CMD ["python", "app.py"]
-`
-	dockerIgnore = `*.pyc
-`
-	appPy = `import os
+FROM {{ .BaseImage }}:{{ .PythonVersion }}-slim
 
-from flask import Flask
-app = Flask(__name__)
+RUN useradd --create-home app
+COPY --from=builder /install /usr/local
+WORKDIR /home/app
+COPY . .
+RUN chown -R app:app /home/app
+USER app
 
-@app.route('/')
-def hello_world():
-    return "Hello World, I\'m a Python Flask app!\n"
+ENV PORT {{ .Port }}
+EXPOSE {{ .Port }}
 
-if __name__ == '__main__':
-app.run(host='0.0.0.0', port=int(os.getenv('PORT', 8080)))
-`
-	requirementsTxt = `flask
+CMD [{{ .CMD }}]
 `
-	deploymentTemplate = `kind: Deployment
+	deploymentTemplate = `{% define "probeHandler" %}{% if eq .Kind "httpGet" %}            httpGet:
+              path: {% .Path %}
+              port: {% .Port %}
+{% else if eq .Kind "tcpSocket" %}            tcpSocket:
+              port: {% .Port %}
+{% else if eq .Kind "exec" %}            exec:
+              command:
+{% range .Command %}                - {% . | printf "%q" %}
+{% end %}{% end %}{% end %}kind: Deployment
 apiVersion: apps/v1
 metadata:
   name: {{ template "{% .AppName %}.{% .Name %}.name" . }}
@@ -73,14 +85,30 @@ spec:
         kubed: {{ template "{% .AppName %}.name" . }}
         component: {% .Name %}
     spec:
+      {{- if .Values.{% .Name %}.serviceAccount.create }}
+      serviceAccountName: {{ template "{% .AppName %}.{% .Name %}.serviceAccount.name" . }}
+      {{- end }}
       containers:
         - name: {% .Name %}
           image: "{{ .Values.{% .Name %}.image.repository }}:{{ .Values.{% .Name %}.image.tag }}"
           imagePullPolicy: {{ default .Values.{% .Name %}.image.pullPolicy "IfNotPresent" }}
+          env:
+{{- range .Values.{% .Name %}.env }}
+            - name: {{ .name }}
+              value: {{ .value | quote }}
+{{- end }}
           ports:
-            - name: http
-              containerPort: 8080
+{% range .Ports %}            - name: {% .Name %}
+              containerPort: {% .ContainerPort %}
               protocol: TCP
+{% end %}{% if .LivenessProbe %}          livenessProbe:
+{% template "probeHandler" .LivenessProbe %}            initialDelaySeconds: {{ .Values.{% .Name %}.livenessProbe.initialDelaySeconds }}
+            periodSeconds: {{ .Values.{% .Name %}.livenessProbe.periodSeconds }}
+{% end %}{% if .ReadinessProbe %}          readinessProbe:
+{% template "probeHandler" .ReadinessProbe %}            initialDelaySeconds: {{ .Values.{% .Name %}.readinessProbe.initialDelaySeconds }}
+            periodSeconds: {{ .Values.{% .Name %}.readinessProbe.periodSeconds }}
+{% end %}          resources:
+{{ toYaml .Values.{% .Name %}.resources | indent 12 }}
 `
 	serviceTemplate = `kind: Service
 apiVersion: v1
@@ -95,28 +123,186 @@ spec:
     kubed: {{ template "{% .AppName %}.name" . }}
     component: {% .Name %}
   ports:
-    - port: 80
-      targetPort: http
+{% range .Ports %}    - port: {% .ServicePort %}
+      targetPort: {% .Name %}
       protocol: TCP
-      name: http
+      name: {% .Name %}
+{% end %}`
+	serviceAccountTemplate = `{{- if .Values.{% .Name %}.serviceAccount.create }}
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: {{ template "{% .AppName %}.{% .Name %}.serviceAccount.name" . }}
+  labels:
+    kubed: {{ template "{% .AppName %}.name" . }}
+    component: {% .Name %}
+    generator: python
+{{- end }}
 `
 	helperTemplate = `
 {{- define "{% .AppName %}.{% .Name %}.name" -}}
 {{- printf "%s-{% .Name %}" .Release.Name | trunc 63 | trimSuffix "-" -}}
 {{- end -}}
+
+{{- define "{% .AppName %}.{% .Name %}.serviceAccount.name" -}}
+{{- printf "%s-{% .Name %}" .Release.Name | trunc 63 | trimSuffix "-" -}}
+{{- end -}}
 `
 	valuesTemplate = `
 {% .Name %}:
   image: {}
+  env: []
+  livenessProbe:
+    initialDelaySeconds: 5
+    periodSeconds: 10
+  readinessProbe:
+    initialDelaySeconds: 5
+    periodSeconds: 10
+  resources: {}
+  serviceAccount:
+    create: false
 `
 )
 
-var flagDebug bool
+// framework describes a scaffolding profile for a supported Python web
+// framework: the files kubed-generator-python writes into the component
+// directory, and the port the resulting container listens on. cmd builds
+// the Dockerfile's CMD argument list for the container's listen port, so
+// a framework that bakes the port into its command line (django, fastapi)
+// stays in sync with port/portName.
+type framework struct {
+	cmd             func(port int) []string
+	entrypointName  string
+	entrypoint      string
+	requirementsTxt string
+	port            int
+	portName        string
+}
+
+// frameworks is the registry of scaffolding profiles known to this
+// generator, keyed by the value passed to --framework.
+var frameworks = map[string]framework{
+	"flask": {
+		cmd: func(port int) []string {
+			return []string{"python", "app.py"}
+		},
+		entrypointName: "app.py",
+		entrypoint: `import os
+
+from flask import Flask
+app = Flask(__name__)
+
+@app.route('/')
+def hello_world():
+    return "Hello World, I\'m a Python Flask app!\n"
+
+if __name__ == '__main__':
+app.run(host='0.0.0.0', port=int(os.getenv('PORT', 8080)))
+`,
+		requirementsTxt: `flask
+`,
+		port:     8080,
+		portName: "http",
+	},
+	"django": {
+		cmd: func(port int) []string {
+			return []string{"python", "manage.py", "runserver", fmt.Sprintf("0.0.0.0:%d", port)}
+		},
+		entrypointName: "manage.py",
+		entrypoint: `#!/usr/bin/env python
+import os
+import sys
+
+if __name__ == '__main__':
+    os.environ.setdefault('DJANGO_SETTINGS_MODULE', 'app.settings')
+    from django.core.management import execute_from_command_line
+    execute_from_command_line(sys.argv)
+`,
+		requirementsTxt: `django
+`,
+		port:     8080,
+		portName: "http",
+	},
+	"fastapi": {
+		cmd: func(port int) []string {
+			return []string{"uvicorn", "main:app", "--host", "0.0.0.0", "--port", fmt.Sprintf("%d", port)}
+		},
+		entrypointName: "main.py",
+		entrypoint: `from fastapi import FastAPI
+
+app = FastAPI()
+
+
+@app.get('/')
+def hello_world():
+    return {"message": "Hello World, I'm a Python FastAPI app!"}
+`,
+		requirementsTxt: `fastapi
+uvicorn
+`,
+		port:     8080,
+		portName: "http",
+	},
+}
+
+// portSpec is a single port exposed by a component's container, along with
+// the Service port it is mapped to.
+type portSpec struct {
+	Name          string
+	ContainerPort int
+	ServicePort   int
+}
+
+// probeSpec is a single liveness/readiness probe's handler: exactly one of
+// Path (with Port, for httpGet), Port alone (for tcpSocket) or Command (for
+// exec) is set, per Kind. A nil probeSpec means the component has no probe
+// of that kind at all, and deploymentTemplate omits the block entirely.
+type probeSpec struct {
+	Kind    string
+	Path    string
+	Port    string
+	Command []string
+}
+
+// chartValues is the data handed to deploymentTemplate, serviceTemplate,
+// serviceAccountTemplate, helperTemplate and valuesTemplate.
+type chartValues struct {
+	AppName        string
+	Name           string
+	GeneratorName  string
+	Ports          []portSpec
+	LivenessProbe  *probeSpec
+	ReadinessProbe *probeSpec
+}
+
+// artifact is a single file (or file fragment, for the files that are
+// appended to rather than created) that run() produces. It is the unit
+// --dry-run reports on.
+type artifact struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// plan is the full set of artifacts and the route that a run would
+// produce. It is what --dry-run --output=yaml|json serializes.
+type plan struct {
+	Artifacts []artifact `json:"artifacts"`
+	Route     string     `json:"route"`
+}
+
+var (
+	flagDebug  bool
+	flagDryRun bool
+)
 
 type generateCmd struct {
 	stdout         io.Writer
 	name           string
 	repositoryName string
+	framework      string
+	output         string
+	pythonVersion  string
+	baseImage      string
 }
 
 func newRootCmd(stdout io.Writer, stdin io.Reader, stderr io.Writer) *cobra.Command {
@@ -143,11 +329,79 @@ func newRootCmd(stdout io.Writer, stdin io.Reader, stderr io.Writer) *cobra.Comm
 
 	pf := cmd.PersistentFlags()
 	pf.BoolVar(&flagDebug, "debug", false, "enable verbose output")
+	pf.BoolVar(&flagDryRun, "dry-run", false, "print the planned file tree and template output without writing")
+	cmd.Flags().StringVar(&c.framework, "framework", defaultFramework, "the Python web framework to scaffold (flask, django, fastapi)")
+	cmd.Flags().StringVar(&c.output, "output", "", "with --dry-run, print the plan as \"yaml\" or \"json\" instead of banners")
+	cmd.Flags().StringVar(&c.pythonVersion, "python-version", defaultPythonVersion, "the Python version to base the Dockerfile's build and runtime stages on")
+	cmd.Flags().StringVar(&c.baseImage, "base-image", defaultBaseImage, "the Docker Hub repository the Dockerfile's build and runtime stages are based on")
+
+	cmd.AddCommand(newImportCmd(stdout))
 
 	return cmd
 }
 
+// renderChart executes the deployment, service, service account, values and
+// helpers templates against values, in that order. It is shared by
+// generateCmd and importCmd so both produce identically-shaped charts.
+func renderChart(values chartValues) (deploymentBuf, serviceBuf, serviceAccountBuf, valuesBuf, helpersBuf bytes.Buffer, err error) {
+	dt := texttemplate.Must(texttemplate.New("deployment").Delims("{%", "%}").Parse(deploymentTemplate))
+	if err = dt.Execute(&deploymentBuf, values); err != nil {
+		return
+	}
+	st := template.Must(template.New("service").Delims("{%", "%}").Parse(serviceTemplate))
+	if err = st.Execute(&serviceBuf, values); err != nil {
+		return
+	}
+	sat := template.Must(template.New("serviceaccount").Delims("{%", "%}").Parse(serviceAccountTemplate))
+	if err = sat.Execute(&serviceAccountBuf, values); err != nil {
+		return
+	}
+	vt := template.Must(template.New("values").Delims("{%", "%}").Parse(valuesTemplate))
+	if err = vt.Execute(&valuesBuf, values); err != nil {
+		return
+	}
+	ht := template.Must(template.New("helpers").Delims("{%", "%}").Parse(helperTemplate))
+	err = ht.Execute(&helpersBuf, values)
+	return
+}
+
+// writeChartFiles writes the first five artifacts of p (deployment, service,
+// service account, values, helpers, in that order) to disk. The deployment,
+// service and service account templates are always regenerated in full;
+// values.yaml and _helpers.tpl are shared across every component in the
+// chart, so their fragments are appended instead.
+func writeChartFiles(p plan) error {
+	create := []int{0, 1, 2}
+	appendTo := []int{3, 4}
+	for _, i := range create {
+		if err := writeArtifact(p.Artifacts[i], os.O_CREATE|os.O_TRUNC|os.O_WRONLY); err != nil {
+			return err
+		}
+	}
+	for _, i := range appendTo {
+		if err := writeArtifact(p.Artifacts[i], os.O_CREATE|os.O_APPEND|os.O_WRONLY); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeArtifact(a artifact, flag int) error {
+	f, err := os.OpenFile(a.Path, flag, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(a.Content)
+	return err
+}
+
 func (c *generateCmd) run() error {
+	fw, found := frameworks[c.framework]
+	if !found {
+		return fmt.Errorf("unknown framework %q: must be one of flask, django, fastapi", c.framework)
+	}
+
 	var config manifest.Manifest
 	tomlFilepath := filepath.Join("config", "kubed.toml")
 	if _, err := toml.DecodeFile(tomlFilepath, &config); err != nil {
@@ -158,54 +412,62 @@ func (c *generateCmd) run() error {
 		return fmt.Errorf("Environment %v not found in %s", defaultEnvironment(), tomlFilepath)
 	}
 
-	deploymentFile, err := os.Create(filepath.Join("charts", appConfig.Name, "templates", fmt.Sprintf("%s-deployment.yaml", c.name)))
-	if err != nil {
-		return err
-	}
-	defer deploymentFile.Close()
-	serviceFile, err := os.Create(filepath.Join("charts", appConfig.Name, "templates", fmt.Sprintf("%s-service.yaml", c.name)))
-	if err != nil {
-		return err
-	}
-	defer serviceFile.Close()
-	valuesFile, err := os.OpenFile(filepath.Join("charts", appConfig.Name, "values.yaml"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
+	// render the helm chart
+	httpProbe := &probeSpec{Kind: "httpGet", Path: "/", Port: fw.portName}
+	values := chartValues{
+		AppName:        appConfig.Name,
+		Name:           c.name,
+		GeneratorName:  "python",
+		Ports:          []portSpec{{Name: fw.portName, ContainerPort: fw.port, ServicePort: 80}},
+		LivenessProbe:  httpProbe,
+		ReadinessProbe: httpProbe,
 	}
-	defer valuesFile.Close()
-	helpersFile, err := os.OpenFile(filepath.Join("charts", appConfig.Name, "templates", "_helpers.tpl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+
+	deploymentBuf, serviceBuf, serviceAccountBuf, valuesBuf, helpersBuf, err := renderChart(values)
 	if err != nil {
 		return err
 	}
-	defer helpersFile.Close()
 
-	// scaffold helm chart
-	values := struct {
-		AppName       string
-		Name          string
-		GeneratorName string
+	// render the Dockerfile
+	dockerfileValues := struct {
+		BaseImage     string
+		PythonVersion string
+		Port          int
+		CMD           string
 	}{
-		AppName:       appConfig.Name,
-		Name:          c.name,
-		GeneratorName: "python",
+		BaseImage:     c.baseImage,
+		PythonVersion: c.pythonVersion,
+		Port:          fw.port,
+		CMD:           dockerCMD(fw.cmd(fw.port)),
 	}
-	dt := template.Must(template.New("deployment").Delims("{%", "%}").Parse(deploymentTemplate))
-	if err := dt.Execute(deploymentFile, values); err != nil {
+	var dockerfileBuf bytes.Buffer
+	dft := texttemplate.Must(texttemplate.New("dockerfile").Parse(dockerfileTemplate))
+	if err := dft.Execute(&dockerfileBuf, dockerfileValues); err != nil {
 		return err
 	}
 
-	st := template.Must(template.New("service").Delims("{%", "%}").Parse(serviceTemplate))
-	if err := st.Execute(serviceFile, values); err != nil {
-		return err
+	route := fmt.Sprintf("/%s/\t%s\t%d", c.name, c.name, fw.port)
+
+	p := plan{
+		Artifacts: []artifact{
+			{Path: filepath.Join("charts", appConfig.Name, "templates", fmt.Sprintf("%s-deployment.yaml", c.name)), Content: deploymentBuf.String()},
+			{Path: filepath.Join("charts", appConfig.Name, "templates", fmt.Sprintf("%s-service.yaml", c.name)), Content: serviceBuf.String()},
+			{Path: filepath.Join("charts", appConfig.Name, "templates", fmt.Sprintf("%s-serviceaccount.yaml", c.name)), Content: serviceAccountBuf.String()},
+			{Path: filepath.Join("charts", appConfig.Name, "values.yaml"), Content: valuesBuf.String()},
+			{Path: filepath.Join("charts", appConfig.Name, "templates", "_helpers.tpl"), Content: helpersBuf.String()},
+			{Path: filepath.Join(c.name, "Dockerfile"), Content: dockerfileBuf.String()},
+			{Path: filepath.Join(c.name, ".dockerignore"), Content: dockerIgnore},
+			{Path: filepath.Join(c.name, fw.entrypointName), Content: fw.entrypoint},
+			{Path: filepath.Join(c.name, "requirements.txt"), Content: fw.requirementsTxt},
+		},
+		Route: route,
 	}
 
-	vt := template.Must(template.New("values").Delims("{%", "%}").Parse(valuesTemplate))
-	if err := vt.Execute(valuesFile, values); err != nil {
-		return err
+	if flagDryRun {
+		return printPlan(c.stdout, c.output, p)
 	}
 
-	ht := template.Must(template.New("helpers").Delims("{%", "%}").Parse(helperTemplate))
-	if err := ht.Execute(helpersFile, values); err != nil {
+	if err := writeChartFiles(p); err != nil {
 		return err
 	}
 
@@ -218,26 +480,67 @@ func (c *generateCmd) run() error {
 		return fmt.Errorf("there was an error checking if %s exists: %v", c.name, err)
 	}
 
-	p := &pack.Pack{
+	pk := &pack.Pack{
 		Files: map[string]io.ReadCloser{
-			"Dockerfile":       ioutil.NopCloser(bytes.NewBufferString(dockerfile)),
+			"Dockerfile":       ioutil.NopCloser(bytes.NewBufferString(dockerfileBuf.String())),
 			".dockerignore":    ioutil.NopCloser(bytes.NewBufferString(dockerIgnore)),
-			"app.py":           ioutil.NopCloser(bytes.NewBufferString(appPy)),
-			"requirements.txt": ioutil.NopCloser(bytes.NewBufferString(requirementsTxt)),
+			fw.entrypointName:  ioutil.NopCloser(bytes.NewBufferString(fw.entrypoint)),
+			"requirements.txt": ioutil.NopCloser(bytes.NewBufferString(fw.requirementsTxt)),
 		},
 	}
 
-	if err := p.SaveDir(c.name); err != nil {
+	if err := pk.SaveDir(c.name); err != nil {
 		return err
 	}
 
-	// Each pack makes the assumption that they're listening on port 8080
-	addRoute(filepath.Join("config", "routes"), fmt.Sprintf("/%s/\t%s\t8080", c.name, c.name))
+	// Each pack makes the assumption that they're listening on fw.port
+	addRoute(filepath.Join("config", "routes"), route)
 
 	fmt.Fprintln(c.stdout, "--> Ready to sail")
 	return nil
 }
 
+// printPlan renders p to stdout, either as banner-delimited file content
+// (the default) or as structured yaml/json when output requests it.
+func printPlan(stdout io.Writer, output string, p plan) error {
+	switch output {
+	case "yaml":
+		out, err := yaml.Marshal(p)
+		if err != nil {
+			return err
+		}
+		_, err = stdout.Write(out)
+		return err
+	case "json":
+		out, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(stdout, string(out))
+		return err
+	case "":
+		for _, a := range p.Artifacts {
+			fmt.Fprintf(stdout, "# --- %s ---\n", a.Path)
+			fmt.Fprintln(stdout, a.Content)
+		}
+		fmt.Fprintf(stdout, "# --- %s (route) ---\n", filepath.Join("config", "routes"))
+		fmt.Fprintln(stdout, p.Route)
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q: must be \"yaml\" or \"json\"", output)
+	}
+}
+
+// dockerCMD renders args as a Dockerfile exec-form CMD argument list, e.g.
+// []string{"python", "app.py"} becomes `"python", "app.py"`.
+func dockerCMD(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = fmt.Sprintf("%q", arg)
+	}
+	return strings.Join(quoted, ", ")
+}
+
 // addRoute adds a new route to fpath. It appends the route
 // above the default route so that it takes higher priority
 // in the list than the static files, but lower priority than
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/BurntSushi/toml"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/bacongobbler/kubed-generator-sdk-go/manifest"
+	"github.com/bacongobbler/kubed-generator-sdk-go/pack"
+)
+
+// importValuesTemplate is valuesTemplate's counterpart for imported
+// components: rather than leaving image and env empty for the user to fill
+// in and probe timings at fixed defaults, it bakes in what was read off the
+// source Deployment.
+const importValuesTemplate = `
+{% .Name %}:
+  image:
+    repository: {% .ImageRepository %}
+    tag: {% .ImageTag %}
+  env:
+{% range .Env %}    - name: {% .Name %}
+      value: {% .Value | printf "%q" %}
+{% end %}  livenessProbe:
+    initialDelaySeconds: {% .LivenessInitialDelaySeconds %}
+    periodSeconds: {% .LivenessPeriodSeconds %}
+  readinessProbe:
+    initialDelaySeconds: {% .ReadinessInitialDelaySeconds %}
+    periodSeconds: {% .ReadinessPeriodSeconds %}
+  resources: {}
+  serviceAccount:
+    create: false
+`
+
+// envVarSpec is a single literal environment variable translated from a
+// source container's env list. EnvVars sourced from ValueFrom (secrets,
+// config maps, the downward API) can't be resolved statically and are
+// skipped.
+type envVarSpec struct {
+	Name  string
+	Value string
+}
+
+type importValues struct {
+	Name                         string
+	ImageRepository              string
+	ImageTag                     string
+	Env                          []envVarSpec
+	LivenessInitialDelaySeconds  int32
+	LivenessPeriodSeconds        int32
+	ReadinessInitialDelaySeconds int32
+	ReadinessPeriodSeconds       int32
+}
+
+type importCmd struct {
+	stdout       io.Writer
+	name         string
+	fromManifest string
+	container    string
+	framework    string
+	output       string
+}
+
+func newImportCmd(stdout io.Writer) *cobra.Command {
+	c := importCmd{stdout: stdout}
+
+	cmd := &cobra.Command{
+		Use:          "import <name> --from-manifest <file>",
+		Short:        "imports an existing Deployment manifest into a kubed chart",
+		Long:         `Reuses an existing Kubernetes Deployment's image, ports, env vars and probes to scaffold a component, instead of the built-in framework defaults.`,
+		SilenceUsage: true,
+		Args:         cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c.name = args[0]
+			return c.run()
+		},
+	}
+
+	cmd.Flags().StringVar(&c.fromManifest, "from-manifest", "", "path to the Deployment manifest to import (required)")
+	cmd.Flags().StringVar(&c.container, "container", "", "the container to import, when the Deployment defines more than one")
+	cmd.Flags().StringVar(&c.framework, "framework", defaultFramework, "the Python web framework to scaffold for the local entrypoint/requirements.txt pack")
+	cmd.Flags().StringVar(&c.output, "output", "", "with --dry-run, print the plan as \"yaml\" or \"json\" instead of banners")
+	cmd.MarkFlagRequired("from-manifest")
+
+	return cmd
+}
+
+func (c *importCmd) run() error {
+	fw, found := frameworks[c.framework]
+	if !found {
+		return fmt.Errorf("unknown framework %q: must be one of flask, django, fastapi", c.framework)
+	}
+
+	container, err := c.readContainer()
+	if err != nil {
+		return err
+	}
+
+	var config manifest.Manifest
+	tomlFilepath := filepath.Join("config", "kubed.toml")
+	if _, err := toml.DecodeFile(tomlFilepath, &config); err != nil {
+		return err
+	}
+	appConfig, found := config.Environments[defaultEnvironment()]
+	if !found {
+		return fmt.Errorf("Environment %v not found in %s", defaultEnvironment(), tomlFilepath)
+	}
+
+	ports, err := containerPorts(container)
+	if err != nil {
+		return err
+	}
+
+	repository, tag := splitImageRef(container.Image)
+
+	env, droppedEnv := containerEnv(container)
+	for _, name := range droppedEnv {
+		log.Warnf("env var %q is sourced from a secret/configMap/the downward API and can't be resolved statically; add it to values.yaml manually", name)
+	}
+
+	values := chartValues{
+		AppName:        appConfig.Name,
+		Name:           c.name,
+		GeneratorName:  "python",
+		Ports:          ports,
+		LivenessProbe:  newProbeSpec(container.LivenessProbe),
+		ReadinessProbe: newProbeSpec(container.ReadinessProbe),
+	}
+
+	deploymentBuf, serviceBuf, serviceAccountBuf, _, helpersBuf, err := renderChart(values)
+	if err != nil {
+		return err
+	}
+
+	var valuesBuf bytes.Buffer
+	ivt := texttemplate.Must(texttemplate.New("import-values").Delims("{%", "%}").Parse(importValuesTemplate))
+	if err := ivt.Execute(&valuesBuf, importValues{
+		Name:                         c.name,
+		ImageRepository:              repository,
+		ImageTag:                     tag,
+		Env:                          env,
+		LivenessInitialDelaySeconds:  probeInitialDelaySeconds(container.LivenessProbe, 5),
+		LivenessPeriodSeconds:        probePeriodSeconds(container.LivenessProbe, 10),
+		ReadinessInitialDelaySeconds: probeInitialDelaySeconds(container.ReadinessProbe, 5),
+		ReadinessPeriodSeconds:       probePeriodSeconds(container.ReadinessProbe, 10),
+	}); err != nil {
+		return err
+	}
+
+	route := fmt.Sprintf("/%s/\t%s\t%d", c.name, c.name, ports[0].ContainerPort)
+
+	p := plan{
+		Artifacts: []artifact{
+			{Path: filepath.Join("charts", appConfig.Name, "templates", fmt.Sprintf("%s-deployment.yaml", c.name)), Content: deploymentBuf.String()},
+			{Path: filepath.Join("charts", appConfig.Name, "templates", fmt.Sprintf("%s-service.yaml", c.name)), Content: serviceBuf.String()},
+			{Path: filepath.Join("charts", appConfig.Name, "templates", fmt.Sprintf("%s-serviceaccount.yaml", c.name)), Content: serviceAccountBuf.String()},
+			{Path: filepath.Join("charts", appConfig.Name, "values.yaml"), Content: valuesBuf.String()},
+			{Path: filepath.Join("charts", appConfig.Name, "templates", "_helpers.tpl"), Content: helpersBuf.String()},
+			{Path: filepath.Join(c.name, ".dockerignore"), Content: dockerIgnore},
+			{Path: filepath.Join(c.name, fw.entrypointName), Content: fw.entrypoint},
+			{Path: filepath.Join(c.name, "requirements.txt"), Content: fw.requirementsTxt},
+		},
+		Route: route,
+	}
+
+	if flagDryRun {
+		return printPlan(c.stdout, c.output, p)
+	}
+
+	if err := writeChartFiles(p); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(c.name); os.IsNotExist(err) {
+		if err := os.Mkdir(c.name, 0777); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return fmt.Errorf("there was an error checking if %s exists: %v", c.name, err)
+	}
+
+	pk := &pack.Pack{
+		Files: map[string]io.ReadCloser{
+			".dockerignore":    ioutil.NopCloser(bytes.NewBufferString(dockerIgnore)),
+			fw.entrypointName:  ioutil.NopCloser(bytes.NewBufferString(fw.entrypoint)),
+			"requirements.txt": ioutil.NopCloser(bytes.NewBufferString(fw.requirementsTxt)),
+		},
+	}
+	if err := pk.SaveDir(c.name); err != nil {
+		return err
+	}
+
+	addRoute(filepath.Join("config", "routes"), route)
+
+	fmt.Fprintln(c.stdout, "--> Ready to sail")
+	return nil
+}
+
+// readContainer loads c.fromManifest and returns the container to import,
+// disambiguated by c.container when the Deployment defines more than one.
+func (c *importCmd) readContainer() (corev1.Container, error) {
+	b, err := ioutil.ReadFile(c.fromManifest)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+
+	var deploy appsv1.Deployment
+	if err := yaml.Unmarshal(b, &deploy); err != nil {
+		return corev1.Container{}, fmt.Errorf("failed to parse %s as a Deployment: %v", c.fromManifest, err)
+	}
+
+	containers := deploy.Spec.Template.Spec.Containers
+	switch len(containers) {
+	case 0:
+		return corev1.Container{}, fmt.Errorf("%s defines no containers", c.fromManifest)
+	case 1:
+		return containers[0], nil
+	}
+
+	if c.container == "" {
+		return corev1.Container{}, fmt.Errorf("%s defines multiple containers; specify one with --container", c.fromManifest)
+	}
+	for _, container := range containers {
+		if container.Name == c.container {
+			return container, nil
+		}
+	}
+	return corev1.Container{}, fmt.Errorf("container %q not found in %s", c.container, c.fromManifest)
+}
+
+// containerPorts maps each of container's ports to a Service port of the
+// same number.
+func containerPorts(container corev1.Container) ([]portSpec, error) {
+	if len(container.Ports) == 0 {
+		return nil, fmt.Errorf("container %q exposes no ports", container.Name)
+	}
+	ports := make([]portSpec, 0, len(container.Ports))
+	for i, cp := range container.Ports {
+		name := cp.Name
+		if name == "" {
+			name = fmt.Sprintf("port-%d", i)
+		}
+		ports = append(ports, portSpec{
+			Name:          name,
+			ContainerPort: int(cp.ContainerPort),
+			ServicePort:   int(cp.ContainerPort),
+		})
+	}
+	return ports, nil
+}
+
+// containerEnv translates container's literal env vars into envVarSpecs.
+// Entries sourced from ValueFrom (secretKeyRef, configMapKeyRef, the
+// downward API) can't be resolved statically and are dropped; their names
+// are returned separately so the caller can warn that they need to be
+// added back manually.
+func containerEnv(container corev1.Container) (env []envVarSpec, dropped []string) {
+	for _, e := range container.Env {
+		if e.ValueFrom != nil {
+			dropped = append(dropped, e.Name)
+			continue
+		}
+		env = append(env, envVarSpec{Name: e.Name, Value: e.Value})
+	}
+	return env, dropped
+}
+
+// newProbeSpec translates a source container's probe into the handler
+// deploymentTemplate renders, preserving whichever of httpGet, tcpSocket or
+// exec the source actually used. It returns nil when p is nil, so the
+// rendered Deployment omits the probe entirely rather than fabricating one.
+func newProbeSpec(p *corev1.Probe) *probeSpec {
+	if p == nil {
+		return nil
+	}
+	switch {
+	case p.HTTPGet != nil:
+		path := p.HTTPGet.Path
+		if path == "" {
+			path = "/"
+		}
+		return &probeSpec{Kind: "httpGet", Path: path, Port: p.HTTPGet.Port.String()}
+	case p.TCPSocket != nil:
+		return &probeSpec{Kind: "tcpSocket", Port: p.TCPSocket.Port.String()}
+	case p.Exec != nil:
+		return &probeSpec{Kind: "exec", Command: p.Exec.Command}
+	default:
+		return nil
+	}
+}
+
+func probeInitialDelaySeconds(p *corev1.Probe, fallback int32) int32 {
+	if p == nil {
+		return fallback
+	}
+	return p.InitialDelaySeconds
+}
+
+func probePeriodSeconds(p *corev1.Probe, fallback int32) int32 {
+	if p == nil || p.PeriodSeconds == 0 {
+		return fallback
+	}
+	return p.PeriodSeconds
+}
+
+// splitImageRef splits a container image reference into its repository and
+// tag, defaulting to "latest" when no tag is present. It only looks at the
+// colon after the last slash, so it isn't fooled by a registry port.
+func splitImageRef(image string) (repository, tag string) {
+	slash := strings.LastIndex(image, "/")
+	colon := strings.LastIndex(image, ":")
+	if colon == -1 || colon < slash {
+		return image, "latest"
+	}
+	return image[:colon], image[colon+1:]
+}